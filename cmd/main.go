@@ -6,16 +6,41 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"github.com/mkimuram/importvolume/pkg/controller"
 	"github.com/mkimuram/importvolume/pkg/importer"
+	"github.com/mkimuram/importvolume/pkg/populator"
 	flag "github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
 	utilflag "k8s.io/component-base/cli/flag"
 )
 
 var (
-	kubeconfig = flag.String("kubeconfig", "", "Absolute path to the kubeconfig file.")
-	namespace  = flag.StringP("namespace", "n", "default", "Namespace to create PersistentVolumeClaim.")
-	file       = flag.StringP("filename", "f", "", "Filename that contains PersistentVolumeClaim definition.")
+	kubeconfig     = flag.String("kubeconfig", "", "Absolute path to the kubeconfig file.")
+	namespace      = flag.StringP("namespace", "n", "default", "Namespace to create PersistentVolumeClaim.")
+	file           = flag.StringP("filename", "f", "", "Filename that contains PersistentVolumeClaim definition.")
+	runController  = flag.Bool("controller", false, "Run as a controller that reconciles ImportVolume objects instead of importing a single file.")
+	controllerSync = flag.Duration("controller-resync-period", 30*time.Second, "Resync period for the ImportVolume informer, used only with --controller.")
+
+	runPopulator  = flag.Bool("populator", false, "Run as a populator controller that imports volumes for PersistentVolumeClaims with an ImportSource dataSourceRef, instead of importing a single file.")
+	populatorSync = flag.Duration("populator-resync-period", 30*time.Second, "Resync period for the populator's PersistentVolumeClaim informer, used only with --populator.")
+
+	verify     = flag.Bool("verify", false, "Verify the volume exists and fetch its real capacity from the CSI controller before importing, instead of blindly trusting the given volumeHandle.")
+	csiAddress = flag.String("csi-address", "/csi/csi.sock", "Address of the CSI controller socket to dial when --verify is set.")
+
+	reclaimPolicy = flag.String("reclaim-policy", "", "Override the StorageClass's ReclaimPolicy for the generated PersistentVolume.")
+	mountOptions  []string
+
+	batch           = flag.Bool("batch", false, "Treat -f as a directory, a multi-document YAML stream, or a manifest file listing {pvcFile, params, volumeHandleTemplate} tuples, and import every volume it contains sequentially.")
+	continueOnError = flag.Bool("continue-on-error", false, "With --batch, keep importing remaining entries after one fails instead of stopping.")
+	batchReport     = flag.String("report", "", "With --batch, write a JSON report of the import results to this path.")
 
 	importParams map[string]string
 	templatePath = "./config"
@@ -23,6 +48,7 @@ var (
 
 func init() {
 	flag.VarP(utilflag.NewMapStringString(&importParams), "parameters", "p", "Parameters to specify the volume to be imported.")
+	flag.StringSliceVar(&mountOptions, "mount-options", nil, "Override the StorageClass's MountOptions for the generated PersistentVolume.")
 
 	flag.Parse()
 	// get the KUBECONFIG from env if specified
@@ -36,7 +62,7 @@ func init() {
 		os.Exit(1)
 	}
 
-	if *file == "" {
+	if *file == "" && !*runController && !*runPopulator {
 		fmt.Fprintf(os.Stderr, "file must be provide with -f option\n")
 		os.Exit(1)
 	}
@@ -49,15 +75,122 @@ func init() {
 }
 
 func main() {
+	if *runController {
+		runControllerMode()
+		return
+	}
+	if *runPopulator {
+		runPopulatorMode()
+		return
+	}
+	if *batch {
+		runBatchMode()
+		return
+	}
+
 	v, err := importer.NewVolumeImporter(*kubeconfig, *namespace, *file, importParams, templatePath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to start importer: %v\n", err)
 		os.Exit(1)
 	}
 
+	if *verify {
+		if err := v.Verify(*csiAddress); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to verify volume: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *reclaimPolicy != "" {
+		v.SetReclaimPolicy(corev1.PersistentVolumeReclaimPolicy(*reclaimPolicy))
+	}
+	if len(mountOptions) > 0 {
+		v.SetMountOptions(mountOptions)
+	}
+
 	err = v.Import()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to import %q: %v\n", file, err)
 		os.Exit(1)
 	}
 }
+
+func runBatchMode() {
+	b, err := importer.NewBatchImporter(*kubeconfig, *namespace, *file, *continueOnError, templatePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start batch importer: %v\n", err)
+		os.Exit(1)
+	}
+
+	results := b.Import()
+
+	importer.PrintSummary(tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0), results)
+
+	if *batchReport != "" {
+		if err := importer.WriteReport(*batchReport, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write report to %q: %v\n", *batchReport, err)
+			os.Exit(1)
+		}
+	}
+
+	for _, r := range results {
+		if r.Error != "" {
+			os.Exit(1)
+		}
+	}
+}
+
+func runControllerMode() {
+	cs, dc := buildClients()
+	stopCh := setupStopChannel()
+
+	c := controller.NewController(cs, dc, *namespace, templatePath, *controllerSync)
+	if err := c.Run(2, stopCh); err != nil {
+		fmt.Fprintf(os.Stderr, "Controller exited with error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runPopulatorMode() {
+	cs, dc := buildClients()
+	stopCh := setupStopChannel()
+
+	c := populator.NewController(cs, dc, templatePath, *populatorSync)
+	if err := c.Run(2, stopCh); err != nil {
+		fmt.Fprintf(os.Stderr, "Populator exited with error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func buildClients() (kubernetes.Interface, dynamic.Interface) {
+	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to build config: %v\n", err)
+		os.Exit(1)
+	}
+
+	cs, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create clientset: %v\n", err)
+		os.Exit(1)
+	}
+
+	dc, err := dynamic.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create dynamic client: %v\n", err)
+		os.Exit(1)
+	}
+
+	return cs, dc
+}
+
+func setupStopChannel() <-chan struct{} {
+	stopCh := make(chan struct{})
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		close(stopCh)
+	}()
+	return stopCh
+}
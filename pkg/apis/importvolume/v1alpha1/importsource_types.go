@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2021 importvolume authors
+// SPDX-License-Identifier: Apache-2.0
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ImportSource describes a foreign CSI volume to populate a PVC from,
+// referenced from a PersistentVolumeClaim's spec.dataSourceRef so that the
+// populator controller can import it the same way importer.VolumeImporter
+// and the ImportVolume controller do, without pre-creating the PV by hand.
+type ImportSource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ImportSourceSpec `json:"spec"`
+}
+
+// ImportSourceSpec identifies the volume to import.
+type ImportSourceSpec struct {
+	// StorageClassName is the source StorageClass whose provisioner,
+	// parameters and policies are used to build the PersistentVolume.
+	StorageClassName string `json:"storageClassName"`
+
+	// Parameters are the CSI volume identifier parameters used to resolve
+	// VolumeHandleTemplate, equivalent to the CLI's -p map.
+	Parameters map[string]string `json:"parameters,omitempty"`
+
+	// VolumeHandleTemplate, when set, overrides the StorageClass
+	// provisioner's template file under the populator's template path for
+	// this object only.
+	// +optional
+	VolumeHandleTemplate string `json:"volumeHandleTemplate,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ImportSourceList is a list of ImportSource resources.
+type ImportSourceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ImportSource `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object, maintained by hand alongside
+// the struct fields above until this package is wired into deepcopy-gen.
+func (in *ImportSource) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := new(ImportSource)
+	out.TypeMeta = in.TypeMeta
+	out.ObjectMeta = *in.ObjectMeta.DeepCopy()
+	out.Spec = *in.Spec.DeepCopy()
+	return out
+}
+
+// DeepCopy returns a deep copy of spec, including its Parameters map.
+func (in *ImportSourceSpec) DeepCopy() *ImportSourceSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ImportSourceSpec)
+	out.StorageClassName = in.StorageClassName
+	out.VolumeHandleTemplate = in.VolumeHandleTemplate
+	if in.Parameters != nil {
+		out.Parameters = make(map[string]string, len(in.Parameters))
+		for k, v := range in.Parameters {
+			out.Parameters[k] = v
+		}
+	}
+	return out
+}
+
+// DeepCopyObject implements runtime.Object.
+func (in *ImportSourceList) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := new(ImportSourceList)
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = *in.ListMeta.DeepCopy()
+	if in.Items != nil {
+		out.Items = make([]ImportSource, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyObject().(*ImportSource).DeepCopyInto(&out.Items[i])
+		}
+	}
+	return out
+}
+
+// DeepCopyInto copies in into out.
+func (in *ImportSource) DeepCopyInto(out *ImportSource) {
+	*out = *in
+	out.ObjectMeta = *in.ObjectMeta.DeepCopy()
+	out.Spec = *in.Spec.DeepCopy()
+}
@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: 2021 importvolume authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package v1alpha1 defines the ImportVolume custom resource, which lets
+// users declare a volume import the same way importer.VolumeImporter
+// performs it from the CLI, but as a Kubernetes object that a controller
+// can reconcile and retry.
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GroupName is the API group this CRD lives in.
+const GroupName = "importvolume.mkimuram.github.com"
+
+// SchemeGroupVersion is the GroupVersion for this API package's types.
+var SchemeGroupVersion = schema.GroupVersion{Group: GroupName, Version: "v1alpha1"}
+
+// ImportVolumePhase describes where an ImportVolume is in the import
+// process.
+type ImportVolumePhase string
+
+const (
+	// ImportVolumePending means the ImportVolume has not been reconciled yet.
+	ImportVolumePending ImportVolumePhase = "Pending"
+	// ImportVolumeBound means the PV and PVC were created and bound successfully.
+	ImportVolumeBound ImportVolumePhase = "Bound"
+	// ImportVolumeFailed means the last reconcile attempt returned an error.
+	ImportVolumeFailed ImportVolumePhase = "Failed"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ImportVolume is the Schema for declaratively importing a pre-existing
+// CSI volume as a PersistentVolume/PersistentVolumeClaim pair.
+type ImportVolume struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ImportVolumeSpec   `json:"spec"`
+	Status ImportVolumeStatus `json:"status,omitempty"`
+}
+
+// ImportVolumeSpec describes the volume to import and how to build its
+// PersistentVolumeClaim.
+type ImportVolumeSpec struct {
+	// PVCTemplate is the spec of the PersistentVolumeClaim that should be
+	// created to bind the imported volume, equivalent to the file passed
+	// to the CLI with -f.
+	PVCTemplate v1.PersistentVolumeClaimSpec `json:"pvcTemplate"`
+
+	// PVCName is the name to give the PersistentVolumeClaim created from
+	// PVCTemplate.
+	PVCName string `json:"pvcName"`
+
+	// StorageClassName is the source StorageClass whose provisioner,
+	// parameters and policies are used to build the PersistentVolume.
+	// It must match PVCTemplate.StorageClassName.
+	StorageClassName string `json:"storageClassName"`
+
+	// Parameters are the CSI volume identifier parameters used to resolve
+	// VolumeHandleTemplate, equivalent to the CLI's -p map.
+	Parameters map[string]string `json:"parameters,omitempty"`
+
+	// VolumeHandleTemplate, when set, overrides the StorageClass
+	// provisioner's template file under the importer's template path for
+	// this object only.
+	// +optional
+	VolumeHandleTemplate string `json:"volumeHandleTemplate,omitempty"`
+}
+
+// ImportVolumeStatus reports the outcome of the last reconcile attempt.
+type ImportVolumeStatus struct {
+	// Phase is the current state of the import.
+	Phase ImportVolumePhase `json:"phase,omitempty"`
+
+	// BoundPVName is the name of the PersistentVolume created for this
+	// import once it has been created.
+	BoundPVName string `json:"boundPVName,omitempty"`
+
+	// VolumeHandle is the volumeHandle resolved from Spec.Parameters and
+	// the StorageClass provisioner's template.
+	VolumeHandle string `json:"volumeHandle,omitempty"`
+
+	// Error is the error returned by createPV or createPVC on the last
+	// failed reconcile attempt, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ImportVolumeList is a list of ImportVolume resources.
+type ImportVolumeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ImportVolume `json:"items"`
+}
+
+// DeepCopyObject implements runtime.Object. This package is not yet wired
+// into deepcopy-gen, so it is maintained by hand alongside the struct
+// fields above.
+func (in *ImportVolume) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := new(ImportVolume)
+	out.TypeMeta = in.TypeMeta
+	out.ObjectMeta = *in.ObjectMeta.DeepCopy()
+	out.Spec = *in.Spec.DeepCopy()
+	out.Status = in.Status
+	return out
+}
+
+// DeepCopy returns a deep copy of spec, including its Parameters map.
+func (in *ImportVolumeSpec) DeepCopy() *ImportVolumeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ImportVolumeSpec)
+	in.PVCTemplate.DeepCopyInto(&out.PVCTemplate)
+	out.PVCName = in.PVCName
+	out.StorageClassName = in.StorageClassName
+	out.VolumeHandleTemplate = in.VolumeHandleTemplate
+	if in.Parameters != nil {
+		out.Parameters = make(map[string]string, len(in.Parameters))
+		for k, v := range in.Parameters {
+			out.Parameters[k] = v
+		}
+	}
+	return out
+}
+
+// DeepCopyObject implements runtime.Object.
+func (in *ImportVolumeList) DeepCopyObject() runtime.Object {
+	if in == nil {
+		return nil
+	}
+	out := new(ImportVolumeList)
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = *in.ListMeta.DeepCopy()
+	if in.Items != nil {
+		out.Items = make([]ImportVolume, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyObject().(*ImportVolume).DeepCopyInto(&out.Items[i])
+		}
+	}
+	return out
+}
+
+// DeepCopyInto copies in into out.
+func (in *ImportVolume) DeepCopyInto(out *ImportVolume) {
+	*out = *in
+	out.ObjectMeta = *in.ObjectMeta.DeepCopy()
+	out.Spec = *in.Spec.DeepCopy()
+}
@@ -0,0 +1,212 @@
+// SPDX-FileCopyrightText: 2021 importvolume authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package controller reconciles ImportVolume objects, driving the same
+// PV/PVC creation path that the CLI in cmd/main.go drives directly, so
+// that imports can be GitOps-managed and retried without shelling out.
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	importvolumev1alpha1 "github.com/mkimuram/importvolume/pkg/apis/importvolume/v1alpha1"
+	"github.com/mkimuram/importvolume/pkg/importer"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// importVolumeResource is the GroupVersionResource of the ImportVolume CRD.
+var importVolumeResource = schema.GroupVersionResource{
+	Group:    importvolumev1alpha1.GroupName,
+	Version:  "v1alpha1",
+	Resource: "importvolumes",
+}
+
+// Controller watches ImportVolume objects and reconciles them by running
+// importer.VolumeImporter against the PVC template and parameters each one
+// declares.
+type Controller struct {
+	cs kubernetes.Interface
+	dc dynamic.Interface
+
+	queue  workqueue.RateLimitingInterface
+	lister cache.GenericLister
+	synced cache.InformerSynced
+
+	namespace    string
+	templatePath string
+}
+
+// NewController creates a Controller that reconciles ImportVolume objects
+// in namespace, resolving provisioner templates under templatePath in the
+// same way the CLI does via the IMPORT_VOLUME_TEMPLATE path.
+func NewController(cs kubernetes.Interface, dc dynamic.Interface, namespace, templatePath string, resync time.Duration) *Controller {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dc, resync, namespace, nil)
+	informer := factory.ForResource(importVolumeResource)
+
+	c := &Controller{
+		cs:           cs,
+		dc:           dc,
+		queue:        workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		lister:       informer.Lister(),
+		synced:       informer.Informer().HasSynced,
+		namespace:    namespace,
+		templatePath: templatePath,
+	}
+
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueue,
+		UpdateFunc: func(old, new interface{}) { c.enqueue(new) },
+	})
+
+	factory.Start(wait.NeverStop)
+
+	return c
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	c.queue.Add(key)
+}
+
+// Run starts workers processing the queue until stopCh is closed.
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) error {
+	defer c.queue.ShutDown()
+
+	if ok := cache.WaitForCacheSync(stopCh, c.synced); !ok {
+		return fmt.Errorf("failed to wait for ImportVolume informer cache to sync")
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.runWorker, time.Second, stopCh)
+	}
+
+	<-stopCh
+	return nil
+}
+
+func (c *Controller) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.reconcile(key.(string)); err != nil {
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *Controller) reconcile(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	obj, err := c.lister.ByNamespace(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected object type %T for ImportVolume %s/%s", obj, namespace, name)
+	}
+
+	iv := &importvolumev1alpha1.ImportVolume{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, iv); err != nil {
+		return err
+	}
+
+	// Already imported: nothing left to do. Without this, every periodic
+	// informer resync would re-run Import() against an object whose PV/PVC
+	// already exist, turn the resulting AlreadyExists error into a bogus
+	// Failed status, and overwrite the Bound status forever.
+	if iv.Status.Phase == importvolumev1alpha1.ImportVolumeBound {
+		return nil
+	}
+
+	return c.importAndUpdateStatus(iv)
+}
+
+// importAndUpdateStatus drives the same PV/PVC creation path as the CLI
+// (importer.VolumeImporter.Import), then records the resolved volumeHandle,
+// bound PV name and the last error from createPV/createPVC onto the
+// ImportVolume's status subresource.
+func (c *Controller) importAndUpdateStatus(iv *importvolumev1alpha1.ImportVolume) error {
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      iv.Spec.PVCName,
+			Namespace: iv.Namespace,
+		},
+		Spec: iv.Spec.PVCTemplate,
+	}
+	pvc.Spec.StorageClassName = &iv.Spec.StorageClassName
+
+	v, err := importer.NewVolumeImporterFromPVCWithTemplate(c.cs, iv.Namespace, pvc, iv.Spec.Parameters, c.templatePath, iv.Spec.VolumeHandleTemplate)
+	if err != nil {
+		return c.updateStatus(iv, "", "", err)
+	}
+
+	importErr := v.Import()
+	if apierrors.IsAlreadyExists(importErr) {
+		// The PV and/or PVC were already created by an earlier, unobserved
+		// reconcile (e.g. a crash between creation and the status update
+		// below); treat that as success rather than a fresh failure.
+		importErr = nil
+	}
+
+	return c.updateStatus(iv, v.PVName(), v.VolumeHandle(), importErr)
+}
+
+func (c *Controller) updateStatus(iv *importvolumev1alpha1.ImportVolume, pvName, volumeHandle string, importErr error) error {
+	iv.Status.BoundPVName = pvName
+	iv.Status.VolumeHandle = volumeHandle
+	if importErr != nil {
+		iv.Status.Phase = importvolumev1alpha1.ImportVolumeFailed
+		iv.Status.Error = importErr.Error()
+	} else {
+		iv.Status.Phase = importvolumev1alpha1.ImportVolumeBound
+		iv.Status.Error = ""
+	}
+
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(iv)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.dc.Resource(importVolumeResource).Namespace(iv.Namespace).UpdateStatus(
+		context.TODO(),
+		&unstructured.Unstructured{Object: obj},
+		metav1.UpdateOptions{},
+	)
+	return err
+}
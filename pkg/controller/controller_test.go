@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: 2021 importvolume authors
+// SPDX-License-Identifier: Apache-2.0
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	importvolumev1alpha1 "github.com/mkimuram/importvolume/pkg/apis/importvolume/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestImportVolume(t *testing.T, name, namespace string, phase importvolumev1alpha1.ImportVolumePhase) *unstructured.Unstructured {
+	t.Helper()
+
+	iv := &importvolumev1alpha1.ImportVolume{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: importvolumev1alpha1.SchemeGroupVersion.String(),
+			Kind:       "ImportVolume",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Status:     importvolumev1alpha1.ImportVolumeStatus{Phase: phase},
+	}
+
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(iv)
+	if err != nil {
+		t.Fatalf("failed to convert ImportVolume to unstructured: %v", err)
+	}
+	return &unstructured.Unstructured{Object: obj}
+}
+
+// TestReconcileSkipsAlreadyBound guards the bug fixed in an earlier commit:
+// every periodic informer resync re-enqueues every ImportVolume, so without
+// this skip, reconcile would re-run Import() against an object whose PV/PVC
+// already exist and flip a successful Bound status to Failed forever.
+func TestReconcileSkipsAlreadyBound(t *testing.T) {
+	namespace := "my-ns"
+	obj := newTestImportVolume(t, "my-import", namespace, importvolumev1alpha1.ImportVolumeBound)
+
+	gvrToListKind := map[schema.GroupVersionResource]string{
+		importVolumeResource: "ImportVolumeList",
+	}
+	dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrToListKind, obj)
+	cs := fake.NewSimpleClientset()
+
+	c := NewController(cs, dc, namespace, "./testdata", time.Minute)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	if !cache.WaitForCacheSync(stopCh, c.synced) {
+		t.Fatal("failed to sync informer cache")
+	}
+
+	if err := c.reconcile(namespace + "/my-import"); err != nil {
+		t.Fatalf("reconcile() returned error: %v", err)
+	}
+
+	pvcs, err := cs.CoreV1().PersistentVolumeClaims(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list PVCs: %v", err)
+	}
+	if len(pvcs.Items) != 0 {
+		t.Errorf("reconcile() created %d PVCs for an already-Bound ImportVolume, want 0", len(pvcs.Items))
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	namespace := "my-ns"
+	name := "my-import"
+
+	tests := []struct {
+		name         string
+		importErr    error
+		wantPhase    importvolumev1alpha1.ImportVolumePhase
+		wantErrorMsg string
+	}{
+		{
+			name:      "success records Bound",
+			wantPhase: importvolumev1alpha1.ImportVolumeBound,
+		},
+		{
+			name:         "failure records Failed with the error message",
+			importErr:    errors.New("boom"),
+			wantPhase:    importvolumev1alpha1.ImportVolumeFailed,
+			wantErrorMsg: "boom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := newTestImportVolume(t, name, namespace, importvolumev1alpha1.ImportVolumePending)
+
+			gvrToListKind := map[schema.GroupVersionResource]string{
+				importVolumeResource: "ImportVolumeList",
+			}
+			dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrToListKind, obj)
+
+			c := &Controller{dc: dc}
+			iv := &importvolumev1alpha1.ImportVolume{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			}
+
+			if err := c.updateStatus(iv, "pv-my-pvc", "vol-1", tt.importErr); err != nil {
+				t.Fatalf("updateStatus() returned error: %v", err)
+			}
+
+			got, err := dc.Resource(importVolumeResource).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get updated ImportVolume: %v", err)
+			}
+
+			phase, _, _ := unstructured.NestedString(got.Object, "status", "phase")
+			if importvolumev1alpha1.ImportVolumePhase(phase) != tt.wantPhase {
+				t.Errorf("status.phase = %q, want %q", phase, tt.wantPhase)
+			}
+
+			errMsg, _, _ := unstructured.NestedString(got.Object, "status", "error")
+			if errMsg != tt.wantErrorMsg {
+				t.Errorf("status.error = %q, want %q", errMsg, tt.wantErrorMsg)
+			}
+		})
+	}
+}
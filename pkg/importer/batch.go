@@ -0,0 +1,250 @@
+// SPDX-FileCopyrightText: 2021 importvolume authors
+// SPDX-License-Identifier: Apache-2.0
+
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	v1 "k8s.io/api/core/v1"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// BatchEntry is one volume to import as part of a batch, equivalent to one
+// CLI invocation's -f and -p.
+type BatchEntry struct {
+	PVCFile              string            `json:"pvcFile"`
+	Params               map[string]string `json:"params,omitempty"`
+	VolumeHandleTemplate string            `json:"volumeHandleTemplate,omitempty"`
+
+	pvc *v1.PersistentVolumeClaim
+}
+
+// BatchResult is the outcome of importing one BatchEntry, used both for the
+// printed summary table and the optional JSON report.
+type BatchResult struct {
+	PVCFile string `json:"pvcFile"`
+	PVCName string `json:"pvcName,omitempty"`
+	PVName  string `json:"pvName,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BatchImporter imports a list of BatchEntry volumes sequentially, unlike
+// VolumeImporter which imports exactly one PVC per invocation.
+type BatchImporter struct {
+	cs              kubernetes.Interface
+	namespace       string
+	templatePath    string
+	continueOnError bool
+
+	entries []BatchEntry
+}
+
+// NewBatchImporter builds a BatchImporter whose entries come from source,
+// which may be a directory of PVC manifests, a single multi-document YAML
+// stream of PVC manifests, or a manifest file listing
+// {pvcFile, params, volumeHandleTemplate} tuples.
+func NewBatchImporter(kubeconfig, ns, source string, continueOnError bool, templatePath string) (*BatchImporter, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	cs, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := loadBatchEntries(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load batch entries from %q: %v", source, err)
+	}
+
+	return &BatchImporter{
+		cs:              cs,
+		namespace:       ns,
+		templatePath:    templatePath,
+		continueOnError: continueOnError,
+		entries:         entries,
+	}, nil
+}
+
+// loadBatchEntries figures out which of the three supported source shapes
+// it was given and returns one BatchEntry per PVC to import.
+func loadBatchEntries(source string) ([]BatchEntry, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return loadBatchEntriesFromDir(source)
+	}
+
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if entries, err := loadBatchEntriesFromManifest(data); err == nil {
+		return entries, nil
+	}
+
+	return loadBatchEntriesFromYAMLStream(source, data)
+}
+
+// loadBatchEntriesFromDir treats every file in dir as a PVC manifest, with
+// no per-entry params or volumeHandleTemplate override.
+func loadBatchEntriesFromDir(dir string) ([]BatchEntry, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	entries := make([]BatchEntry, 0, len(names))
+	for _, name := range names {
+		pvcFile := filepath.Join(dir, name)
+		pvc, err := parsePVCfile(pvcFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %v", pvcFile, err)
+		}
+		entries = append(entries, BatchEntry{PVCFile: pvcFile, pvc: pvc})
+	}
+
+	return entries, nil
+}
+
+// loadBatchEntriesFromManifest decodes source as a manifest file listing
+// {pvcFile, params, volumeHandleTemplate} tuples. It errors out if source
+// doesn't look like a manifest, so the caller can fall back to treating it
+// as a multi-document PVC YAML stream instead.
+func loadBatchEntriesFromManifest(data []byte) ([]BatchEntry, error) {
+	var entries []BatchEntry
+	if err := sigsyaml.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("manifest has no entries")
+	}
+
+	for i := range entries {
+		if entries[i].PVCFile == "" {
+			return nil, fmt.Errorf("manifest entry %d is missing pvcFile", i)
+		}
+		pvc, err := parsePVCfile(entries[i].PVCFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %v", entries[i].PVCFile, err)
+		}
+		entries[i].pvc = pvc
+	}
+
+	return entries, nil
+}
+
+// loadBatchEntriesFromYAMLStream decodes source as a single file containing
+// multiple "---"-separated PersistentVolumeClaim documents.
+func loadBatchEntriesFromYAMLStream(source string, data []byte) ([]BatchEntry, error) {
+	decoder := k8syaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+
+	var entries []BatchEntry
+	for {
+		pvc := &v1.PersistentVolumeClaim{}
+		if err := decoder.Decode(pvc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if pvc.Name == "" {
+			continue
+		}
+		entries = append(entries, BatchEntry{PVCFile: fmt.Sprintf("%s#%s", source, pvc.Name), pvc: pvc})
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no PersistentVolumeClaim documents found in %q", source)
+	}
+
+	return entries, nil
+}
+
+// Import imports every entry sequentially. When continueOnError is false,
+// it stops at the first failure; either way, every entry attempted so far
+// is included in the returned results.
+func (b *BatchImporter) Import() []BatchResult {
+	results := make([]BatchResult, 0, len(b.entries))
+
+	for _, entry := range b.entries {
+		result := b.importEntry(entry)
+		results = append(results, result)
+
+		if result.Error != "" && !b.continueOnError {
+			break
+		}
+	}
+
+	return results
+}
+
+func (b *BatchImporter) importEntry(entry BatchEntry) BatchResult {
+	result := BatchResult{PVCFile: entry.PVCFile, PVCName: entry.pvc.Name}
+
+	v, err := NewVolumeImporterFromPVCWithTemplate(b.cs, b.namespace, entry.pvc, entry.Params, b.templatePath, entry.VolumeHandleTemplate)
+	if err != nil {
+		result.Status = "Failed"
+		result.Error = err.Error()
+		return result
+	}
+	result.PVName = v.PVName()
+
+	if err := v.Import(); err != nil {
+		result.Status = "Failed"
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Status = "Imported"
+	return result
+}
+
+// PrintSummary writes a PVC -> PV -> status table to w.
+func PrintSummary(w *tabwriter.Writer, results []BatchResult) {
+	fmt.Fprintln(w, "PVC\tPV\tSTATUS")
+	for _, r := range results {
+		status := r.Status
+		if r.Error != "" {
+			status = fmt.Sprintf("%s: %s", r.Status, r.Error)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.PVCName, r.PVName, status)
+	}
+	w.Flush()
+}
+
+// WriteReport writes results to path as a JSON report.
+func WriteReport(path string, results []BatchResult) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
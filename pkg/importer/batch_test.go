@@ -0,0 +1,54 @@
+// SPDX-FileCopyrightText: 2021 importvolume authors
+// SPDX-License-Identifier: Apache-2.0
+
+package importer
+
+import (
+	"testing"
+)
+
+func TestLoadBatchEntriesFromYAMLStream(t *testing.T) {
+	stream := []byte(`
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: pvc-a
+spec:
+  accessModes: ["ReadWriteOnce"]
+  resources:
+    requests:
+      storage: 1Gi
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: pvc-b
+spec:
+  accessModes: ["ReadWriteOnce"]
+  resources:
+    requests:
+      storage: 2Gi
+`)
+
+	entries, err := loadBatchEntriesFromYAMLStream("stream.yaml", stream)
+	if err != nil {
+		t.Fatalf("loadBatchEntriesFromYAMLStream() returned error: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].pvc.Name != "pvc-a" || entries[1].pvc.Name != "pvc-b" {
+		t.Errorf("got PVC names %q, %q, want pvc-a, pvc-b", entries[0].pvc.Name, entries[1].pvc.Name)
+	}
+}
+
+func TestLoadBatchEntriesFromManifest(t *testing.T) {
+	manifest := []byte(`
+- pvcFile: /does/not/exist.yaml
+`)
+
+	if _, err := loadBatchEntriesFromManifest(manifest); err == nil {
+		t.Fatal("loadBatchEntriesFromManifest() with a missing pvcFile should return an error")
+	}
+}
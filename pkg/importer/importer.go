@@ -20,12 +20,18 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
 )
 
 const (
 	pvPrefix           = "pv-"
-	tokenFsTypeKey     = "csi.storage.k8s.io/fsType"
 	csiParameterPrefix = "csi.storage.k8s.io/"
+	// tokenFsTypeKey and tokenFsTypeKeyLower are the two forms
+	// external-provisioner accepts for the prefixed fsType parameter; both
+	// take precedence over the deprecated unprefixed deprecatedFsTypeKey.
+	tokenFsTypeKey      = csiParameterPrefix + "fsType"
+	tokenFsTypeKeyLower = csiParameterPrefix + "fstype"
+	deprecatedFsTypeKey = "fstype"
 )
 
 type VolumeImporter struct {
@@ -34,47 +40,78 @@ type VolumeImporter struct {
 
 	templatePath string
 	importParams map[string]string
+	// volumeHandleTemplate overrides the provisioner's template file under
+	// templatePath when set.
+	volumeHandleTemplate string
 
 	pvc *v1.PersistentVolumeClaim
 	sc  *storagev1.StorageClass
 
-	pvName       string
-	volumeHandle string
-	capacity     resource.Quantity
-	attributes   map[string]string
-	readOnly     bool
-	volumeMode   *v1.PersistentVolumeMode
-	fsType       string
+	pvName        string
+	volumeHandle  string
+	capacity      resource.Quantity
+	attributes    map[string]string
+	readOnly      bool
+	volumeMode    *v1.PersistentVolumeMode
+	fsType        string
+	reclaimPolicy v1.PersistentVolumeReclaimPolicy
+	mountOptions  []string
 
 	controllerPublishSecret *v1.SecretReference
 	nodeStageSecret         *v1.SecretReference
 	nodePublishSecret       *v1.SecretReference
 	controllerExpandSecret  *v1.SecretReference
+	nodeExpandSecret        *v1.SecretReference
 }
 
 func NewVolumeImporter(kubeconfig, ns, file string, importParams map[string]string, templatePath string) (*VolumeImporter, error) {
-	v := &VolumeImporter{
-		namespace:    ns,
-		importParams: importParams,
-		templatePath: templatePath,
-	}
 	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
 	if err != nil {
 		return nil, err
 	}
 
-	v.cs, err = kubernetes.NewForConfig(config)
+	cs, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
 
-	v.pvc, err = parsePVCfile(file)
+	pvc, err := parsePVCfile(file)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse file %q: %v", file, err)
 	}
+
+	return NewVolumeImporterFromPVC(cs, ns, pvc, importParams, templatePath)
+}
+
+// NewVolumeImporterFromPVC builds a VolumeImporter from an in-memory
+// PersistentVolumeClaim instead of a file on disk, so that callers other
+// than the CLI, such as the ImportVolume controller, can reuse the same
+// PV/PVC creation path without shelling out to a file.
+func NewVolumeImporterFromPVC(cs kubernetes.Interface, ns string, pvc *v1.PersistentVolumeClaim, importParams map[string]string, templatePath string) (*VolumeImporter, error) {
+	return newVolumeImporterFromPVC(cs, ns, pvc, importParams, templatePath, "")
+}
+
+// NewVolumeImporterFromPVCWithTemplate is like NewVolumeImporterFromPVC but
+// overrides the StorageClass provisioner's template file with
+// volumeHandleTemplate, mirroring the ImportVolume CRD's per-object
+// VolumeHandleTemplate field.
+func NewVolumeImporterFromPVCWithTemplate(cs kubernetes.Interface, ns string, pvc *v1.PersistentVolumeClaim, importParams map[string]string, templatePath, volumeHandleTemplate string) (*VolumeImporter, error) {
+	return newVolumeImporterFromPVC(cs, ns, pvc, importParams, templatePath, volumeHandleTemplate)
+}
+
+func newVolumeImporterFromPVC(cs kubernetes.Interface, ns string, pvc *v1.PersistentVolumeClaim, importParams map[string]string, templatePath, volumeHandleTemplate string) (*VolumeImporter, error) {
+	v := &VolumeImporter{
+		cs:                   cs,
+		namespace:            ns,
+		importParams:         importParams,
+		templatePath:         templatePath,
+		volumeHandleTemplate: volumeHandleTemplate,
+		pvc:                  pvc,
+	}
 	// Set namespace to pvc here for pvc.Namespace is referenced as pvc's namespace, later
 	v.pvc.Namespace = v.namespace
 
+	var err error
 	v.sc, err = v.cs.StorageV1().StorageClasses().Get(context.TODO(), *v.pvc.Spec.StorageClassName, metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get StorageClass %q: %v", *v.pvc.Spec.StorageClassName, err)
@@ -98,10 +135,38 @@ func NewVolumeImporter(kubeconfig, ns, file string, importParams map[string]stri
 	// volumeMode should be set before calling genFsType()
 	v.fsType = v.genFsType()
 	v.attributes = v.genAttributes()
+	if v.sc.ReclaimPolicy != nil {
+		v.reclaimPolicy = *v.sc.ReclaimPolicy
+	}
+	v.mountOptions = v.sc.MountOptions
 
 	return v, nil
 }
 
+// SetReclaimPolicy overrides the StorageClass's ReclaimPolicy for this
+// import, for the CLI's --reclaim-policy flag.
+func (v *VolumeImporter) SetReclaimPolicy(policy v1.PersistentVolumeReclaimPolicy) {
+	v.reclaimPolicy = policy
+}
+
+// SetMountOptions overrides the StorageClass's MountOptions for this
+// import, for the CLI's --mount-options flag.
+func (v *VolumeImporter) SetMountOptions(mountOptions []string) {
+	v.mountOptions = mountOptions
+}
+
+// PVName returns the name of the PersistentVolume that Import will create
+// or has created for this volume.
+func (v *VolumeImporter) PVName() string {
+	return v.pvName
+}
+
+// VolumeHandle returns the volumeHandle resolved from the StorageClass
+// provisioner's template and the importer's parameters.
+func (v *VolumeImporter) VolumeHandle() string {
+	return v.volumeHandle
+}
+
 func (v *VolumeImporter) setSecret() error {
 	var err error
 
@@ -125,6 +190,11 @@ func (v *VolumeImporter) setSecret() error {
 		return err
 	}
 
+	v.nodeExpandSecret, err = util.GetSecret(v.sc.Parameters, util.NodeExpandSecret, v.pvName, v.pvc)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -134,8 +204,21 @@ func (v *VolumeImporter) genFsType() string {
 		return ""
 	}
 
-	if fsType, ok := v.sc.Parameters[tokenFsTypeKey]; ok {
-		return fsType
+	prefixed, prefixedOK := v.sc.Parameters[tokenFsTypeKey]
+	if !prefixedOK {
+		prefixed, prefixedOK = v.sc.Parameters[tokenFsTypeKeyLower]
+	}
+	deprecated, deprecatedOK := v.sc.Parameters[deprecatedFsTypeKey]
+
+	if prefixedOK && deprecatedOK {
+		klog.Warningf("StorageClass %q sets both a prefixed fsType parameter and the deprecated %q parameter; the prefixed value %q wins", v.sc.Name, deprecatedFsTypeKey, prefixed)
+	}
+
+	if prefixedOK {
+		return prefixed
+	}
+	if deprecatedOK {
+		return deprecated
 	}
 
 	// TODO: Each CSI driver has it's own default?
@@ -187,13 +270,17 @@ func parsePVCfile(file string) (*v1.PersistentVolumeClaim, error) {
 }
 
 func (v *VolumeImporter) genVolumeHandle() (string, error) {
-	template, err := ioutil.ReadFile(path.Join(v.templatePath, v.sc.Provisioner))
-	if err != nil {
-		return "", err
+	template := v.volumeHandleTemplate
+	if template == "" {
+		raw, err := ioutil.ReadFile(path.Join(v.templatePath, v.sc.Provisioner))
+		if err != nil {
+			return "", err
+		}
+		template = strings.TrimSuffix(string(raw), "\n")
 	}
 
 	missingParams := sets.NewString()
-	volumeHandle := os.Expand(strings.TrimSuffix(string(template), "\n"), func(k string) string {
+	volumeHandle := os.Expand(template, func(k string) string {
 		val, ok := v.importParams[k]
 		if !ok {
 			missingParams.Insert(k)
@@ -216,17 +303,14 @@ func (v *VolumeImporter) createPV() error {
 			},
 		},
 		Spec: v1.PersistentVolumeSpec{
-			StorageClassName: *v.pvc.Spec.StorageClassName,
+			StorageClassName:              *v.pvc.Spec.StorageClassName,
+			PersistentVolumeReclaimPolicy: v.reclaimPolicy,
+			MountOptions:                  v.mountOptions,
 			Capacity: v1.ResourceList{
 				v1.ResourceStorage: v.capacity,
 			},
-			AccessModes: v.pvc.Spec.AccessModes,
-			ClaimRef: &v1.ObjectReference{
-				Kind:       "PersistentVolumeClaim",
-				APIVersion: "v1",
-				Namespace:  v.pvc.Namespace,
-				Name:       v.pvc.Name,
-			},
+			AccessModes:  v.pvc.Spec.AccessModes,
+			NodeAffinity: nodeAffinityFromTopologies(v.sc.AllowedTopologies),
 			PersistentVolumeSource: v1.PersistentVolumeSource{
 				CSI: &v1.CSIPersistentVolumeSource{
 					Driver:                     v.sc.Provisioner,
@@ -235,6 +319,7 @@ func (v *VolumeImporter) createPV() error {
 					NodeStageSecretRef:         v.nodeStageSecret,
 					NodePublishSecretRef:       v.nodePublishSecret,
 					ControllerExpandSecretRef:  v.controllerExpandSecret,
+					NodeExpandSecretRef:        v.nodeExpandSecret,
 					VolumeAttributes:           v.attributes,
 					FSType:                     v.fsType,
 					ReadOnly:                   v.readOnly,
@@ -243,6 +328,17 @@ func (v *VolumeImporter) createPV() error {
 		},
 	}
 
+	// With WaitForFirstConsumer, the PV shouldn't be pre-bound to the PVC so
+	// that the scheduler can still pick the node before binding happens.
+	if v.sc.VolumeBindingMode == nil || *v.sc.VolumeBindingMode != storagev1.VolumeBindingWaitForFirstConsumer {
+		pv.Spec.ClaimRef = &v1.ObjectReference{
+			Kind:       "PersistentVolumeClaim",
+			APIVersion: "v1",
+			Namespace:  v.pvc.Namespace,
+			Name:       v.pvc.Name,
+		}
+	}
+
 	_, err := v.cs.CoreV1().PersistentVolumes().Create(context.TODO(), pv, metav1.CreateOptions{})
 	if err != nil {
 		return err
@@ -251,6 +347,32 @@ func (v *VolumeImporter) createPV() error {
 	return nil
 }
 
+// nodeAffinityFromTopologies converts a StorageClass's AllowedTopologies
+// into the equivalent PersistentVolume NodeAffinity, so the generated PV
+// is only schedulable onto nodes the StorageClass allows.
+func nodeAffinityFromTopologies(topologies []v1.TopologySelectorTerm) *v1.VolumeNodeAffinity {
+	if len(topologies) == 0 {
+		return nil
+	}
+
+	terms := make([]v1.NodeSelectorTerm, 0, len(topologies))
+	for _, topology := range topologies {
+		var expressions []v1.NodeSelectorRequirement
+		for _, expr := range topology.MatchLabelExpressions {
+			expressions = append(expressions, v1.NodeSelectorRequirement{
+				Key:      expr.Key,
+				Operator: v1.NodeSelectorOpIn,
+				Values:   expr.Values,
+			})
+		}
+		terms = append(terms, v1.NodeSelectorTerm{MatchExpressions: expressions})
+	}
+
+	return &v1.VolumeNodeAffinity{
+		Required: &v1.NodeSelector{NodeSelectorTerms: terms},
+	}
+}
+
 func (v *VolumeImporter) createPVC() error {
 	_, err := v.cs.CoreV1().PersistentVolumeClaims(v.namespace).Create(context.TODO(), v.pvc, metav1.CreateOptions{})
 	if err != nil {
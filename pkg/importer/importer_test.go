@@ -0,0 +1,209 @@
+// SPDX-FileCopyrightText: 2021 importvolume authors
+// SPDX-License-Identifier: Apache-2.0
+
+package importer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGenFsType(t *testing.T) {
+	blockMode := v1.PersistentVolumeBlock
+
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		volumeMode *v1.PersistentVolumeMode
+		want       string
+	}{
+		{
+			name:       "prefixed fsType wins",
+			parameters: map[string]string{tokenFsTypeKey: "ext4"},
+			want:       "ext4",
+		},
+		{
+			name:       "prefixed lower-case fstype",
+			parameters: map[string]string{tokenFsTypeKeyLower: "xfs"},
+			want:       "xfs",
+		},
+		{
+			name:       "deprecated unprefixed fstype",
+			parameters: map[string]string{deprecatedFsTypeKey: "ext3"},
+			want:       "ext3",
+		},
+		{
+			name: "prefixed wins over deprecated when both set",
+			parameters: map[string]string{
+				tokenFsTypeKey:      "ext4",
+				deprecatedFsTypeKey: "ext3",
+			},
+			want: "ext4",
+		},
+		{
+			name:       "no fsType parameter set",
+			parameters: map[string]string{},
+			want:       "",
+		},
+		{
+			name:       "block mode always returns empty fsType",
+			parameters: map[string]string{tokenFsTypeKey: "ext4"},
+			volumeMode: &blockMode,
+			want:       "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &VolumeImporter{
+				sc:         &storagev1.StorageClass{Parameters: tt.parameters},
+				volumeMode: tt.volumeMode,
+			}
+
+			if got := v.genFsType(); got != tt.want {
+				t.Errorf("genFsType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeAffinityFromTopologies(t *testing.T) {
+	tests := []struct {
+		name       string
+		topologies []v1.TopologySelectorTerm
+		want       *v1.VolumeNodeAffinity
+	}{
+		{
+			name:       "no topologies",
+			topologies: nil,
+			want:       nil,
+		},
+		{
+			name: "single topology with single expression",
+			topologies: []v1.TopologySelectorTerm{
+				{
+					MatchLabelExpressions: []v1.TopologySelectorLabelRequirement{
+						{Key: "topology.kubernetes.io/zone", Values: []string{"zone-a"}},
+					},
+				},
+			},
+			want: &v1.VolumeNodeAffinity{
+				Required: &v1.NodeSelector{
+					NodeSelectorTerms: []v1.NodeSelectorTerm{
+						{
+							MatchExpressions: []v1.NodeSelectorRequirement{
+								{Key: "topology.kubernetes.io/zone", Operator: v1.NodeSelectorOpIn, Values: []string{"zone-a"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nodeAffinityFromTopologies(tt.topologies); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("nodeAffinityFromTopologies() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreatePV(t *testing.T) {
+	reclaimPolicy := v1.PersistentVolumeReclaimRetain
+	immediate := storagev1.VolumeBindingImmediate
+	waitForFirstConsumer := storagev1.VolumeBindingWaitForFirstConsumer
+
+	tests := []struct {
+		name            string
+		bindingMode     *storagev1.VolumeBindingMode
+		wantClaimRefSet bool
+	}{
+		{
+			name:            "immediate binding pre-binds ClaimRef",
+			bindingMode:     &immediate,
+			wantClaimRefSet: true,
+		},
+		{
+			name:            "no binding mode set pre-binds ClaimRef",
+			bindingMode:     nil,
+			wantClaimRefSet: true,
+		},
+		{
+			name:        "WaitForFirstConsumer leaves ClaimRef unset so the scheduler can still pick the node",
+			bindingMode: &waitForFirstConsumer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := &storagev1.StorageClass{
+				ObjectMeta:        metav1.ObjectMeta{Name: "my-sc"},
+				Provisioner:       "driver.example.com",
+				ReclaimPolicy:     &reclaimPolicy,
+				MountOptions:      []string{"hard", "nfsvers=4.1"},
+				VolumeBindingMode: tt.bindingMode,
+				AllowedTopologies: []v1.TopologySelectorTerm{
+					{
+						MatchLabelExpressions: []v1.TopologySelectorLabelRequirement{
+							{Key: "topology.kubernetes.io/zone", Values: []string{"zone-a"}},
+						},
+					},
+				},
+			}
+			pvc := &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-pvc", Namespace: "my-ns"},
+				Spec: v1.PersistentVolumeClaimSpec{
+					StorageClassName: &sc.Name,
+					AccessModes:      []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+				},
+			}
+
+			cs := fake.NewSimpleClientset()
+			v := &VolumeImporter{
+				cs:            cs,
+				namespace:     pvc.Namespace,
+				pvc:           pvc,
+				sc:            sc,
+				pvName:        pvPrefix + pvc.Name,
+				volumeHandle:  "vol-1",
+				reclaimPolicy: *sc.ReclaimPolicy,
+				mountOptions:  sc.MountOptions,
+			}
+
+			if err := v.createPV(); err != nil {
+				t.Fatalf("createPV() returned error: %v", err)
+			}
+
+			pv, err := cs.CoreV1().PersistentVolumes().Get(context.TODO(), v.pvName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get created PV: %v", err)
+			}
+
+			if pv.Spec.PersistentVolumeReclaimPolicy != reclaimPolicy {
+				t.Errorf("PersistentVolumeReclaimPolicy = %q, want %q", pv.Spec.PersistentVolumeReclaimPolicy, reclaimPolicy)
+			}
+			if !reflect.DeepEqual(pv.Spec.MountOptions, sc.MountOptions) {
+				t.Errorf("MountOptions = %v, want %v", pv.Spec.MountOptions, sc.MountOptions)
+			}
+			if pv.Spec.NodeAffinity == nil {
+				t.Errorf("NodeAffinity = nil, want a node affinity derived from AllowedTopologies")
+			}
+
+			if tt.wantClaimRefSet {
+				if pv.Spec.ClaimRef == nil || pv.Spec.ClaimRef.Name != pvc.Name || pv.Spec.ClaimRef.Namespace != pvc.Namespace {
+					t.Errorf("ClaimRef = %#v, want a reference to %s/%s", pv.Spec.ClaimRef, pvc.Namespace, pvc.Name)
+				}
+			} else if pv.Spec.ClaimRef != nil {
+				t.Errorf("ClaimRef = %#v, want nil", pv.Spec.ClaimRef)
+			}
+		})
+	}
+}
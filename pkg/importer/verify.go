@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2021 importvolume authors
+// SPDX-License-Identifier: Apache-2.0
+
+package importer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const csiDialTimeout = 30 * time.Second
+
+// Verify dials the CSI controller socket at csiEndpoint and confirms that
+// v.volumeHandle refers to an existing volume whose real capacity is
+// sufficient for the PVC being imported, mirroring the blind-import
+// behavior NewVolumeImporter otherwise trusts the user-supplied
+// volumeHandle for. It is only run when the caller passes --verify, since
+// not every user has access to the CSI controller's socket.
+//
+// If the real volume is smaller than the PVC request, Verify returns an
+// error: the claim could never be satisfied. If it is larger, v.capacity
+// is updated to the real size so the generated PV matches the backing
+// volume instead of merely the PVC request.
+func (v *VolumeImporter) Verify(csiEndpoint string) error {
+	conn, err := connectCSI(csiEndpoint)
+	if err != nil {
+		return fmt.Errorf("failed to connect to CSI controller at %q: %v", csiEndpoint, err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), csiDialTimeout)
+	defer cancel()
+
+	client := csi.NewControllerClient(conn)
+
+	actual, err := getVolumeCapacityBytes(ctx, client, v.volumeHandle)
+	if err != nil {
+		return err
+	}
+
+	// A CSI driver reporting capacity_bytes == 0 means the real size is
+	// unset/unknown, not that the volume is empty (CSI spec), so there's
+	// nothing to compare against the PVC request or size up to.
+	if actual == 0 {
+		return nil
+	}
+
+	requested := v.capacity.Value()
+	if actual < requested {
+		return fmt.Errorf("volume %q has capacity %d bytes, smaller than the %d bytes requested by PVC %q", v.volumeHandle, actual, requested, v.pvc.Name)
+	}
+	if actual > requested {
+		v.capacity = *resource.NewQuantity(actual, resource.BinarySI)
+	}
+
+	// TODO: ControllerGetVolume/ListVolumes don't report which access modes
+	// the volume supports, so unlike Trident's canPVMatchWithPVC we can't
+	// verify v.pvc.Spec.AccessModes against the backing volume here.
+
+	return nil
+}
+
+// getVolumeCapacityBytes looks up volumeHandle via ControllerGetVolume,
+// falling back to paging through ListVolumes for drivers that don't
+// implement ControllerGetVolume.
+func getVolumeCapacityBytes(ctx context.Context, client csi.ControllerClient, volumeHandle string) (int64, error) {
+	getResp, err := client.ControllerGetVolume(ctx, &csi.ControllerGetVolumeRequest{VolumeId: volumeHandle})
+	if err == nil {
+		return getResp.GetVolume().GetCapacityBytes(), nil
+	}
+	if status.Code(err) != codes.Unimplemented {
+		return 0, fmt.Errorf("ControllerGetVolume(%q) failed: %v", volumeHandle, err)
+	}
+
+	token := ""
+	for {
+		listResp, err := client.ListVolumes(ctx, &csi.ListVolumesRequest{StartingToken: token})
+		if err != nil {
+			return 0, fmt.Errorf("ListVolumes failed while looking for volume %q: %v", volumeHandle, err)
+		}
+
+		for _, entry := range listResp.GetEntries() {
+			if entry.GetVolume().GetVolumeId() == volumeHandle {
+				return entry.GetVolume().GetCapacityBytes(), nil
+			}
+		}
+
+		token = listResp.GetNextToken()
+		if token == "" {
+			return 0, fmt.Errorf("volume %q not found on CSI controller", volumeHandle)
+		}
+	}
+}
+
+// connectCSI dials a CSI controller endpoint, which is always a unix
+// domain socket (e.g. unix:///csi/csi.sock).
+func connectCSI(endpoint string) (*grpc.ClientConn, error) {
+	addr := strings.TrimPrefix(endpoint, "unix://")
+
+	return grpc.Dial(
+		addr,
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+		}),
+	)
+}
@@ -0,0 +1,280 @@
+// SPDX-FileCopyrightText: 2021 importvolume authors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package populator implements an AnyVolumeDataSource-based import mode,
+// modeled on CDI's upload populator: instead of the CLI or the
+// ImportVolume controller pre-creating a bound PV, a user creates an
+// ordinary PVC with spec.dataSourceRef pointing at an ImportSource, and
+// this controller drives the import to completion, reusing the same
+// secret and attribute resolution importer.VolumeImporter already does.
+package populator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	importvolumev1alpha1 "github.com/mkimuram/importvolume/pkg/apis/importvolume/v1alpha1"
+	"github.com/mkimuram/importvolume/pkg/importer"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// importSourceKind is the dataSourceRef.Kind that routes a PVC to this
+// controller.
+const importSourceKind = "ImportSource"
+
+// primePVCPrefix names the populator-owned PVC that temporarily claims the
+// imported PV while it is being populated, mirroring CDI's "prime" claim.
+const primePVCPrefix = "prime-"
+
+var importSourceResource = schema.GroupVersionResource{
+	Group:    importvolumev1alpha1.GroupName,
+	Version:  "v1alpha1",
+	Resource: "importsources",
+}
+
+// Controller watches PersistentVolumeClaims with an ImportSource
+// dataSourceRef and drives them to completion.
+type Controller struct {
+	cs kubernetes.Interface
+	dc dynamic.Interface
+
+	queue  workqueue.RateLimitingInterface
+	lister corev1listers.PersistentVolumeClaimLister
+	synced cache.InformerSynced
+
+	templatePath string
+}
+
+// NewController creates a populator Controller that resolves provisioner
+// templates under templatePath, the same path the CLI and the
+// ImportVolume controller use.
+func NewController(cs kubernetes.Interface, dc dynamic.Interface, templatePath string, resync time.Duration) *Controller {
+	factory := informers.NewSharedInformerFactory(cs, resync)
+	pvcInformer := factory.Core().V1().PersistentVolumeClaims()
+
+	c := &Controller{
+		cs:           cs,
+		dc:           dc,
+		queue:        workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		lister:       pvcInformer.Lister(),
+		synced:       pvcInformer.Informer().HasSynced,
+		templatePath: templatePath,
+	}
+
+	pvcInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueue,
+		UpdateFunc: func(old, new interface{}) { c.enqueue(new) },
+	})
+
+	factory.Start(wait.NeverStop)
+
+	return c
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	pvc, ok := obj.(*v1.PersistentVolumeClaim)
+	if !ok {
+		return
+	}
+
+	if isImportSourcePVC(pvc) {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			return
+		}
+		c.queue.Add(key)
+		return
+	}
+
+	// The prime PVC has its DataSourceRef cleared (see createPrimeClaim), so
+	// isImportSourcePVC never matches it; without this, the prime PVC
+	// transitioning to Bound would only resume the original PVC's reconcile
+	// on the next periodic resync instead of right away.
+	if ownerKey, ok := primeOwnerKey(pvc); ok {
+		c.queue.Add(ownerKey)
+	}
+}
+
+func isImportSourcePVC(pvc *v1.PersistentVolumeClaim) bool {
+	ref := pvc.Spec.DataSourceRef
+	return ref != nil && ref.Kind == importSourceKind && ref.APIGroup != nil && *ref.APIGroup == importvolumev1alpha1.GroupName
+}
+
+// primeOwnerKey returns the namespace/name key of the PVC that owns pvc as
+// its prime claim (see createPrimeClaim's OwnerReference), if any.
+func primeOwnerKey(pvc *v1.PersistentVolumeClaim) (string, bool) {
+	for _, ref := range pvc.OwnerReferences {
+		if ref.Kind == "PersistentVolumeClaim" {
+			return pvc.Namespace + "/" + ref.Name, true
+		}
+	}
+	return "", false
+}
+
+// Run starts workers processing the queue until stopCh is closed.
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) error {
+	defer c.queue.ShutDown()
+
+	if ok := cache.WaitForCacheSync(stopCh, c.synced); !ok {
+		return fmt.Errorf("failed to wait for PersistentVolumeClaim informer cache to sync")
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.runWorker, time.Second, stopCh)
+	}
+
+	<-stopCh
+	return nil
+}
+
+func (c *Controller) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.reconcile(key.(string)); err != nil {
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+// reconcile drives one PVC through the populator dance: create a prime PVC
+// and a PV bound to it, wait for the prime PVC to be populated, then
+// rebind the PV's ClaimRef onto the original PVC and delete the prime PVC.
+func (c *Controller) reconcile(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	pvc, err := c.lister.PersistentVolumeClaims(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if pvc.Status.Phase == v1.ClaimBound {
+		// Already populated and bound; nothing left to do.
+		return nil
+	}
+
+	src, err := c.getImportSource(namespace, pvc.Spec.DataSourceRef.Name)
+	if err != nil {
+		return err
+	}
+
+	primeName := primePVCPrefix + string(pvc.UID)
+	prime, err := c.cs.CoreV1().PersistentVolumeClaims(namespace).Get(context.TODO(), primeName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return c.createPrimeClaim(pvc, src, primeName)
+	}
+	if err != nil {
+		return err
+	}
+
+	if prime.Status.Phase != v1.ClaimBound {
+		// Still waiting for the CSI driver to bind the prime claim; come
+		// back once the informer observes a status update.
+		return nil
+	}
+
+	return c.rebind(pvc, prime)
+}
+
+func (c *Controller) getImportSource(namespace, name string) (*importvolumev1alpha1.ImportSource, error) {
+	obj, err := c.dc.Resource(importSourceResource).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ImportSource %s/%s: %v", namespace, name, err)
+	}
+
+	src := &importvolumev1alpha1.ImportSource{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, src); err != nil {
+		return nil, err
+	}
+	return src, nil
+}
+
+// createPrimeClaim creates the prime PVC and its backing PV by reusing
+// importer.VolumeImporter's secret/attribute resolution and PV/PVC
+// creation path, the same one the CLI and the ImportVolume controller use.
+func (c *Controller) createPrimeClaim(pvc *v1.PersistentVolumeClaim, src *importvolumev1alpha1.ImportSource, primeName string) error {
+	prime := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      primeName,
+			Namespace: pvc.Namespace,
+			// Lets enqueue map prime PVC update events back onto the
+			// original PVC's key; see primeOwnerKey.
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "v1",
+					Kind:       "PersistentVolumeClaim",
+					Name:       pvc.Name,
+					UID:        pvc.UID,
+				},
+			},
+		},
+		Spec: pvc.Spec,
+	}
+	prime.Spec.DataSourceRef = nil
+	prime.Spec.DataSource = nil
+	prime.Spec.StorageClassName = &src.Spec.StorageClassName
+
+	v, err := importer.NewVolumeImporterFromPVCWithTemplate(c.cs, pvc.Namespace, prime, src.Spec.Parameters, c.templatePath, src.Spec.VolumeHandleTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to prepare import for PVC %s/%s: %v", pvc.Namespace, pvc.Name, err)
+	}
+
+	if err := v.Import(); err != nil {
+		return fmt.Errorf("failed to import volume for PVC %s/%s: %v", pvc.Namespace, pvc.Name, err)
+	}
+
+	return nil
+}
+
+// rebind hands the PV created for the prime claim over to the original
+// PVC by repointing its ClaimRef, then deletes the now-unneeded prime
+// claim so the CSI driver's external PV controller completes the bind.
+func (c *Controller) rebind(pvc *v1.PersistentVolumeClaim, prime *v1.PersistentVolumeClaim) error {
+	pv, err := c.cs.CoreV1().PersistentVolumes().Get(context.TODO(), prime.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	pv.Spec.ClaimRef = &v1.ObjectReference{
+		Kind:       "PersistentVolumeClaim",
+		APIVersion: "v1",
+		Namespace:  pvc.Namespace,
+		Name:       pvc.Name,
+		UID:        pvc.UID,
+	}
+	if _, err := c.cs.CoreV1().PersistentVolumes().Update(context.TODO(), pv, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to rebind PV %q onto PVC %s/%s: %v", pv.Name, pvc.Namespace, pvc.Name, err)
+	}
+
+	return c.cs.CoreV1().PersistentVolumeClaims(prime.Namespace).Delete(context.TODO(), prime.Name, metav1.DeleteOptions{})
+}
@@ -0,0 +1,98 @@
+// SPDX-FileCopyrightText: 2021 importvolume authors
+// SPDX-License-Identifier: Apache-2.0
+
+package populator
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRebind(t *testing.T) {
+	namespace := "my-ns"
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-pvc", Namespace: namespace, UID: "pvc-uid"},
+	}
+	prime := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "prime-pvc-uid", Namespace: namespace},
+		Spec:       v1.PersistentVolumeClaimSpec{VolumeName: "pv-1"},
+	}
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-1"},
+		Spec: v1.PersistentVolumeSpec{
+			ClaimRef: &v1.ObjectReference{
+				Kind:       "PersistentVolumeClaim",
+				APIVersion: "v1",
+				Namespace:  namespace,
+				Name:       prime.Name,
+			},
+		},
+	}
+
+	cs := fake.NewSimpleClientset(prime, pv)
+	c := &Controller{cs: cs}
+
+	if err := c.rebind(pvc, prime); err != nil {
+		t.Fatalf("rebind() returned error: %v", err)
+	}
+
+	gotPV, err := cs.CoreV1().PersistentVolumes().Get(context.TODO(), pv.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get PV: %v", err)
+	}
+	if gotPV.Spec.ClaimRef == nil || gotPV.Spec.ClaimRef.Name != pvc.Name || gotPV.Spec.ClaimRef.UID != pvc.UID {
+		t.Errorf("ClaimRef = %#v, want a reference to %s (uid %s)", gotPV.Spec.ClaimRef, pvc.Name, pvc.UID)
+	}
+
+	if _, err := cs.CoreV1().PersistentVolumeClaims(namespace).Get(context.TODO(), prime.Name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("prime PVC still exists after rebind(), want it deleted (err=%v)", err)
+	}
+}
+
+// TestPrimeOwnerKey guards the event-driven enqueue path added alongside
+// createPrimeClaim's OwnerReference: without it, a prime PVC's transition
+// to Bound would only resume the original PVC's reconcile on the next
+// periodic resync.
+func TestPrimeOwnerKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		pvc     *v1.PersistentVolumeClaim
+		wantKey string
+		wantOK  bool
+	}{
+		{
+			name: "owned by a PVC",
+			pvc: &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "prime-abc",
+					Namespace: "ns",
+					OwnerReferences: []metav1.OwnerReference{
+						{Kind: "PersistentVolumeClaim", Name: "my-pvc"},
+					},
+				},
+			},
+			wantKey: "ns/my-pvc",
+			wantOK:  true,
+		},
+		{
+			name: "no owner reference",
+			pvc: &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, ok := primeOwnerKey(tt.pvc)
+			if ok != tt.wantOK || key != tt.wantKey {
+				t.Errorf("primeOwnerKey() = (%q, %v), want (%q, %v)", key, ok, tt.wantKey, tt.wantOK)
+			}
+		})
+	}
+}
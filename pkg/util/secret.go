@@ -20,6 +20,7 @@ const (
 	NodeStageSecret         secretType = "NodeStageSecret"
 	NodePublishSecret       secretType = "NodePublishSecret"
 	ControllerExpandSecret  secretType = "ControllerExpandSecret"
+	NodeExpandSecret        secretType = "NodeExpandSecret"
 
 	tokenPVNameKey       = "pv.name"
 	tokenPVCNameKey      = "pvc.name"
@@ -33,6 +34,7 @@ var (
 		NodeStageSecret:         "csi.storage.k8s.io/node-stage-secret-namespace",
 		NodePublishSecret:       "csi.storage.k8s.io/node-publish-secret-namespace",
 		ControllerExpandSecret:  "csi.storage.k8s.io/controller-expand-secret-namespace",
+		NodeExpandSecret:        "csi.storage.k8s.io/node-expand-secret-namespace",
 	}
 	deprecatedNsKey = map[secretType]string{
 		ProvisionerSecret:       "provisioner-secret-namespace",
@@ -40,6 +42,7 @@ var (
 		NodeStageSecret:         "node-stage-secret-namespace",
 		NodePublishSecret:       "node-publish-secret-namespace",
 		ControllerExpandSecret:  "controller-expand-secret-namespace",
+		NodeExpandSecret:        "node-expand-secret-namespace",
 	}
 	nameKey = map[secretType]string{
 		ProvisionerSecret:       "csi.storage.k8s.io/provisioner-secret-name",
@@ -47,6 +50,7 @@ var (
 		NodeStageSecret:         "csi.storage.k8s.io/node-stage-secret-name",
 		NodePublishSecret:       "csi.storage.k8s.io/node-publish-secret-name",
 		ControllerExpandSecret:  "csi.storage.k8s.io/controller-expand-secret-name",
+		NodeExpandSecret:        "csi.storage.k8s.io/node-expand-secret-name",
 	}
 	deprecatedNameKey = map[secretType]string{
 		ProvisionerSecret:       "provisioner-secret-name",
@@ -54,6 +58,7 @@ var (
 		NodeStageSecret:         "node-stage-secret-name",
 		NodePublishSecret:       "node-publish-secret-name",
 		ControllerExpandSecret:  "controller-expand-secret-name",
+		NodeExpandSecret:        "node-expand-secret-name",
 	}
 )
 
@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2021 importvolume authors
+// SPDX-License-Identifier: Apache-2.0
+
+package util
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetSecretFallback(t *testing.T) {
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-pvc", Namespace: "my-ns"},
+	}
+
+	tests := []struct {
+		name       string
+		sType      secretType
+		parameters map[string]string
+		wantNs     string
+		wantName   string
+		wantNil    bool
+	}{
+		{
+			name:  "prefixed keys",
+			sType: NodeExpandSecret,
+			parameters: map[string]string{
+				"csi.storage.k8s.io/node-expand-secret-namespace": "${pvc.namespace}",
+				"csi.storage.k8s.io/node-expand-secret-name":      "${pvc.name}-expand",
+			},
+			wantNs:   "my-ns",
+			wantName: "my-pvc-expand",
+		},
+		{
+			name:  "deprecated unprefixed keys",
+			sType: NodeExpandSecret,
+			parameters: map[string]string{
+				"node-expand-secret-namespace": "${pvc.namespace}",
+				"node-expand-secret-name":      "${pvc.name}-expand",
+			},
+			wantNs:   "my-ns",
+			wantName: "my-pvc-expand",
+		},
+		{
+			name:       "not set",
+			sType:      NodeExpandSecret,
+			parameters: map[string]string{},
+			wantNil:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetSecret(tt.parameters, tt.sType, "pv-my-pvc", pvc)
+			if err != nil {
+				t.Fatalf("GetSecret() returned error: %v", err)
+			}
+
+			if tt.wantNil {
+				if got != nil {
+					t.Fatalf("GetSecret() = %+v, want nil", got)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Fatalf("GetSecret() = nil, want namespace %q, name %q", tt.wantNs, tt.wantName)
+			}
+			if got.Namespace != tt.wantNs || got.Name != tt.wantName {
+				t.Errorf("GetSecret() = %+v, want namespace %q, name %q", got, tt.wantNs, tt.wantName)
+			}
+		})
+	}
+}